@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultDateFormat is the time.Parse layout assumed for DATA_TYPE_DATE
+// fields that don't set Field.Format.
+const defaultDateFormat = "2006-01-02"
+
+// defaultHandlerName is used when a schema has no metadata header selecting
+// a handler, preserving the original fixed-width behavior.
+const defaultHandlerName = "fixed-width"
+
+// DataHandler parses a single line of a data file into a field-name-to-value
+// map according to schema. recordNumber (1-based) is folded into any
+// returned error so a caller processing many records can tell which one
+// failed. Extension reports the data file extension this handler expects to
+// pair with (e.g. "csv" for a data file named "foo.csv").
+type DataHandler interface {
+	ParseRecord(recordNumber int, line string, schema []Field) (map[string]interface{}, error)
+	Extension() string
+}
+
+// dataHandlers is the registry of known handlers, keyed by the name used in
+// a schema's ".handler" metadata file.
+var dataHandlers = map[string]DataHandler{
+	"fixed-width": FixedWidthHandler{},
+	"csv":         CSVHandler{},
+	"jsonl":       JSONLHandler{},
+}
+
+// getDataHandler looks up the handler registered under name.
+func getDataHandler(name string) (DataHandler, error) {
+	handler, ok := dataHandlers[name]
+	if !ok {
+		return nil, fmt.Errorf("no data handler registered for %q", name)
+	}
+
+	return handler, nil
+}
+
+// getHandlerForSchema determines which DataHandler a schema file pairs with.
+// If a "<base>.handler" metadata file exists alongside the schema, its
+// trimmed contents name the handler to use; otherwise the fixed-width
+// handler is assumed, preserving the original behavior.
+func getHandlerForSchema(source Source, schemaFileName string) (DataHandler, error) {
+	dotIndex := strings.LastIndex(schemaFileName, ".")
+	if dotIndex == -1 {
+		return nil, fmt.Errorf("schema filename %q has no extension", schemaFileName)
+	}
+
+	baseName := schemaFileName[:dotIndex]
+	handlerFile, openErr := source.Open("schemas/" + baseName + ".handler")
+	if openErr != nil {
+		if errors.Is(openErr, errSourceFileNotFound) {
+			return getDataHandler(defaultHandlerName)
+		}
+		return nil, openErr
+	}
+	defer handlerFile.Close()
+
+	handlerFileContents, readErr := ioutil.ReadAll(handlerFile)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return getDataHandler(strings.TrimSpace(string(handlerFileContents)))
+}
+
+// convertFieldValue converts a raw string value to the Go type implied by
+// field.Type, shared by the handlers that read plain-text columns. A
+// nullable field whose raw value is all whitespace converts to nil rather
+// than failing to parse.
+func convertFieldValue(field Field, rawValue string) (interface{}, error) {
+	if field.Nullable && strings.TrimSpace(rawValue) == "" {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case DATA_TYPE_BOOLEAN:
+		boolVal, boolErr := strconv.ParseBool(rawValue)
+		if boolErr != nil {
+			return nil, fmt.Errorf("field %q: invalid boolean value %q: %w", field.Name, rawValue, boolErr)
+		}
+		return boolVal, nil
+	case DATA_TYPE_INTEGER:
+		intVal, intErr := strconv.Atoi(rawValue)
+		if intErr != nil {
+			return nil, fmt.Errorf("field %q: invalid integer value %q: %w", field.Name, rawValue, intErr)
+		}
+		return intVal, nil
+	case DATA_TYPE_FLOAT:
+		floatVal, floatErr := strconv.ParseFloat(rawValue, 64)
+		if floatErr != nil {
+			return nil, fmt.Errorf("field %q: invalid float value %q: %w", field.Name, rawValue, floatErr)
+		}
+		return floatVal, nil
+	case DATA_TYPE_DATE:
+		layout := field.Format
+		if layout == "" {
+			layout = defaultDateFormat
+		}
+		dateVal, dateErr := time.Parse(layout, rawValue)
+		if dateErr != nil {
+			return nil, fmt.Errorf("field %q: invalid date value %q for format %q: %w", field.Name, rawValue, layout, dateErr)
+		}
+		return dateVal, nil
+	default:
+		return rawValue, nil
+	}
+}
+
+// FixedWidthHandler parses a record as a sequence of fixed-width columns,
+// matching the processor's original data format.
+type FixedWidthHandler struct{}
+
+func (FixedWidthHandler) Extension() string { return "txt" }
+
+func (FixedWidthHandler) ParseRecord(recordNumber int, line string, schema []Field) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(schema))
+
+	startIndex := 0
+	for _, field := range schema {
+		rawValue, consumed, sliceErr := sliceFixedWidthField(line, startIndex, field)
+		if sliceErr != nil {
+			return nil, fmt.Errorf("record %d: %w", recordNumber, sliceErr)
+		}
+
+		value, convertErr := convertFieldValue(field, strings.TrimSpace(rawValue))
+		if convertErr != nil {
+			return nil, fmt.Errorf("record %d: %w", recordNumber, convertErr)
+		}
+		data[field.Name] = value
+
+		startIndex += consumed
+	}
+
+	return data, nil
+}
+
+// forceRuneWidths returns a copy of schema with every field's WidthUnit set
+// to WIDTH_UNIT_RUNES, for use against a data file that had to be
+// transcoded from a non-UTF-8 encoding: each original byte became exactly
+// one rune, so counting runes (not bytes) is what still lines up with the
+// file's original columns.
+func forceRuneWidths(schema []Field) []Field {
+	adjusted := make([]Field, len(schema))
+	for i, field := range schema {
+		field.WidthUnit = WIDTH_UNIT_RUNES
+		adjusted[i] = field
+	}
+
+	return adjusted
+}
+
+// sliceFixedWidthField extracts field's raw value from line starting at the
+// given byte offset, honoring field.WidthUnit. It returns the raw value and
+// the number of bytes consumed, which can exceed field.Width when
+// WidthUnit is WIDTH_UNIT_RUNES and the column contains multi-byte runes.
+// A line that is too short for the field's width produces a descriptive
+// error rather than panicking on an out-of-range slice.
+func sliceFixedWidthField(line string, startIndex int, field Field) (string, int, error) {
+	if startIndex > len(line) {
+		return "", 0, fmt.Errorf("field %q: byte offset %d is past the end of the record (length %d)", field.Name, startIndex, len(line))
+	}
+	if field.Width < 0 {
+		return "", 0, fmt.Errorf("field %q: width %d is negative", field.Name, field.Width)
+	}
+
+	if field.WidthUnit != WIDTH_UNIT_RUNES {
+		if startIndex+field.Width > len(line) {
+			return "", 0, fmt.Errorf("field %q: byte offset %d: need %d bytes but only %d remain", field.Name, startIndex, field.Width, len(line)-startIndex)
+		}
+		return line[startIndex : startIndex+field.Width], field.Width, nil
+	}
+
+	remaining := line[startIndex:]
+	byteOffset := 0
+	for runeIndex := 0; runeIndex < field.Width; runeIndex++ {
+		if byteOffset >= len(remaining) {
+			return "", 0, fmt.Errorf("field %q: byte offset %d: need %d runes but only %d available", field.Name, startIndex, field.Width, runeIndex)
+		}
+		_, runeSize := utf8.DecodeRuneInString(remaining[byteOffset:])
+		byteOffset += runeSize
+	}
+
+	return remaining[:byteOffset], byteOffset, nil
+}
+
+// CSVHandler parses a record as a single CSV row, with columns in schema
+// order.
+type CSVHandler struct{}
+
+func (CSVHandler) Extension() string { return "csv" }
+
+func (CSVHandler) ParseRecord(recordNumber int, line string, schema []Field) (map[string]interface{}, error) {
+	csvReader := csv.NewReader(strings.NewReader(line))
+	columns, readErr := csvReader.Read()
+	if readErr != nil {
+		return nil, fmt.Errorf("record %d: parsing CSV record: %w", recordNumber, readErr)
+	}
+
+	if len(columns) != len(schema) {
+		return nil, fmt.Errorf("record %d: CSV record has %d columns, schema has %d fields", recordNumber, len(columns), len(schema))
+	}
+
+	data := make(map[string]interface{}, len(schema))
+	for i, field := range schema {
+		value, convertErr := convertFieldValue(field, strings.TrimSpace(columns[i]))
+		if convertErr != nil {
+			return nil, fmt.Errorf("record %d: %w", recordNumber, convertErr)
+		}
+		data[field.Name] = value
+	}
+
+	return data, nil
+}
+
+// JSONLHandler parses a record as a single line of newline-delimited JSON,
+// requiring every schema field to be present as a top-level key.
+type JSONLHandler struct{}
+
+func (JSONLHandler) Extension() string { return "jsonl" }
+
+func (JSONLHandler) ParseRecord(recordNumber int, line string, schema []Field) (map[string]interface{}, error) {
+	var raw map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal([]byte(line), &raw); unmarshalErr != nil {
+		return nil, fmt.Errorf("record %d: parsing JSONL record: %w", recordNumber, unmarshalErr)
+	}
+
+	data := make(map[string]interface{}, len(schema))
+	for _, field := range schema {
+		rawValue, ok := raw[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("record %d: JSONL record missing field %q", recordNumber, field.Name)
+		}
+
+		value, convertErr := convertJsonFieldValue(field, rawValue)
+		if convertErr != nil {
+			return nil, fmt.Errorf("record %d: %w", recordNumber, convertErr)
+		}
+		data[field.Name] = value
+	}
+
+	return data, nil
+}
+
+// convertJsonFieldValue decodes a raw JSON value into the Go type implied by
+// field.Type. A nullable field whose raw value is JSON null decodes to nil.
+func convertJsonFieldValue(field Field, rawValue json.RawMessage) (interface{}, error) {
+	if field.Nullable && string(rawValue) == "null" {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case DATA_TYPE_BOOLEAN:
+		var boolVal bool
+		if unmarshalErr := json.Unmarshal(rawValue, &boolVal); unmarshalErr != nil {
+			return nil, fmt.Errorf("field %q: invalid boolean value: %w", field.Name, unmarshalErr)
+		}
+		return boolVal, nil
+	case DATA_TYPE_INTEGER:
+		var intVal int
+		if unmarshalErr := json.Unmarshal(rawValue, &intVal); unmarshalErr != nil {
+			return nil, fmt.Errorf("field %q: invalid integer value: %w", field.Name, unmarshalErr)
+		}
+		return intVal, nil
+	case DATA_TYPE_FLOAT:
+		var floatVal float64
+		if unmarshalErr := json.Unmarshal(rawValue, &floatVal); unmarshalErr != nil {
+			return nil, fmt.Errorf("field %q: invalid float value: %w", field.Name, unmarshalErr)
+		}
+		return floatVal, nil
+	case DATA_TYPE_DATE:
+		var dateStr string
+		if unmarshalErr := json.Unmarshal(rawValue, &dateStr); unmarshalErr != nil {
+			return nil, fmt.Errorf("field %q: invalid date value: %w", field.Name, unmarshalErr)
+		}
+
+		layout := field.Format
+		if layout == "" {
+			layout = defaultDateFormat
+		}
+		dateVal, dateErr := time.Parse(layout, dateStr)
+		if dateErr != nil {
+			return nil, fmt.Errorf("field %q: invalid date value %q for format %q: %w", field.Name, dateStr, layout, dateErr)
+		}
+		return dateVal, nil
+	default:
+		var strVal string
+		if unmarshalErr := json.Unmarshal(rawValue, &strVal); unmarshalErr != nil {
+			return nil, fmt.Errorf("field %q: invalid text value: %w", field.Name, unmarshalErr)
+		}
+		return strVal, nil
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// FuzzFixedWidthHandlerParseRecord exercises ParseRecord against random
+// schema/record pairs, looking for panics (e.g. out-of-range slices) rather
+// than any particular parsed result.
+func FuzzFixedWidthHandlerParseRecord(f *testing.F) {
+	f.Add(3, "bytes", "abc")
+	f.Add(3, "runes", "héllo")
+	f.Add(0, "bytes", "")
+	f.Add(5, "runes", "")
+
+	f.Fuzz(func(t *testing.T, width int, widthUnit string, line string) {
+		if width < 0 {
+			width = -width
+		}
+
+		schema := []Field{
+			{Name: "field_a", Width: width, Type: DATA_TYPE_TEXT, Nullable: true, WidthUnit: widthUnit},
+		}
+
+		// ParseRecord should never panic, regardless of how line and the
+		// schema's width/width unit line up; a mismatch is reported as an
+		// error, not a crash.
+		_, _ = FixedWidthHandler{}.ParseRecord(1, line, schema)
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestGetDataHandlerRegistry checks that every built-in handler name
+// resolves to the expected implementation, and that an unknown name
+// produces a descriptive error instead of a nil handler.
+func TestGetDataHandlerRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    DataHandler
+		wantErr bool
+	}{
+		{name: "fixed-width", want: FixedWidthHandler{}},
+		{name: "csv", want: CSVHandler{}},
+		{name: "jsonl", want: JSONLHandler{}},
+		{name: "xml", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, err := getDataHandler(tc.name)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("getDataHandler(%q) = %v, want error", tc.name, handler)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getDataHandler(%q) returned error: %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(handler, tc.want) {
+				t.Fatalf("getDataHandler(%q) = %#v, want %#v", tc.name, handler, tc.want)
+			}
+		})
+	}
+}
+
+// TestCSVHandlerParseRecord checks column-to-field conversion and the
+// column-count mismatch error.
+func TestCSVHandlerParseRecord(t *testing.T) {
+	schema := []Field{
+		{Name: "name", Type: DATA_TYPE_TEXT},
+		{Name: "age", Type: DATA_TYPE_INTEGER},
+	}
+
+	data, err := CSVHandler{}.ParseRecord(1, "Alice, 30", schema)
+	if err != nil {
+		t.Fatalf("ParseRecord returned error: %v", err)
+	}
+
+	want := map[string]interface{}{"name": "Alice", "age": 30}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("ParseRecord = %#v, want %#v", data, want)
+	}
+
+	if _, err := (CSVHandler{}).ParseRecord(2, "Alice, 30, extra", schema); err == nil {
+		t.Fatal("ParseRecord with extra column: want error, got nil")
+	}
+}
+
+// TestJSONLHandlerParseRecord checks field extraction, type conversion, and
+// the missing-field error.
+func TestJSONLHandlerParseRecord(t *testing.T) {
+	schema := []Field{
+		{Name: "name", Type: DATA_TYPE_TEXT},
+		{Name: "active", Type: DATA_TYPE_BOOLEAN},
+	}
+
+	data, err := JSONLHandler{}.ParseRecord(1, `{"name": "Bob", "active": true}`, schema)
+	if err != nil {
+		t.Fatalf("ParseRecord returned error: %v", err)
+	}
+
+	want := map[string]interface{}{"name": "Bob", "active": true}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("ParseRecord = %#v, want %#v", data, want)
+	}
+
+	if _, err := (JSONLHandler{}).ParseRecord(2, `{"name": "Bob"}`, schema); err == nil {
+		t.Fatal("ParseRecord with missing field: want error, got nil")
+	}
+}
+
+// TestSliceFixedWidthFieldRunes checks that a WIDTH_UNIT_RUNES field consumes
+// the right number of bytes when the column contains multi-byte runes.
+func TestSliceFixedWidthFieldRunes(t *testing.T) {
+	field := Field{Name: "name", Width: 3, WidthUnit: WIDTH_UNIT_RUNES}
+
+	value, consumed, err := sliceFixedWidthField("héllo", 0, field)
+	if err != nil {
+		t.Fatalf("sliceFixedWidthField returned error: %v", err)
+	}
+	if value != "hél" {
+		t.Fatalf("sliceFixedWidthField value = %q, want %q", value, "hél")
+	}
+	if consumed != len("hél") {
+		t.Fatalf("sliceFixedWidthField consumed = %d, want %d", consumed, len("hél"))
+	}
+}
+
+// TestForceRuneWidthsKeepsTranscodedColumnsAligned is a regression test for
+// a transcoded Latin-1 record being mis-sliced under byte-width semantics:
+// "caf\xE9XY" decodes to "caféXY" (one extra UTF-8 byte for "é"), which
+// would shift every later WIDTH_UNIT_BYTES column by one byte. Forcing
+// WIDTH_UNIT_RUNES keeps a 3/3 column split aligned on the original columns
+// regardless of the transcoding.
+func TestForceRuneWidthsKeepsTranscodedColumnsAligned(t *testing.T) {
+	schema := []Field{
+		{Name: "name", Width: 3, Type: DATA_TYPE_TEXT},
+		{Name: "code", Width: 3, Type: DATA_TYPE_TEXT},
+	}
+
+	decoded, transcoded := decodeToUTF8([]byte("caf\xE9XY"))
+	if !transcoded {
+		t.Fatal("decodeToUTF8: want transcoded=true for Latin-1 input")
+	}
+
+	data, err := FixedWidthHandler{}.ParseRecord(1, decoded, forceRuneWidths(schema))
+	if err != nil {
+		t.Fatalf("ParseRecord returned error: %v", err)
+	}
+
+	want := map[string]interface{}{"name": "caf", "code": "éXY"}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("ParseRecord = %#v, want %#v", data, want)
+	}
+}
+
+// TestSliceFixedWidthFieldShortLine checks that a line shorter than the
+// field's width produces an error rather than panicking.
+func TestSliceFixedWidthFieldShortLine(t *testing.T) {
+	field := Field{Name: "name", Width: 10}
+
+	if _, _, err := sliceFixedWidthField("short", 0, field); err == nil {
+		t.Fatal("sliceFixedWidthField with short line: want error, got nil")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "unicode/utf8"
+
+// windows1252Table maps bytes 0x80-0x9F to their Windows-1252 code points.
+// Bytes outside that range are identical to their Unicode code point,
+// matching both ASCII and Latin-1 (ISO-8859-1).
+var windows1252Table = [32]rune{
+	0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+}
+
+// decodeToUTF8 returns data decoded as UTF-8 if it already is valid UTF-8,
+// along with false since no transcoding was needed. Otherwise it assumes
+// data is Windows-1252/Latin-1 encoded, transcodes it, and returns true:
+// every input byte maps to exactly one output rune, so a WIDTH_UNIT_RUNES
+// fixed-width field still lines up with the original single-byte columns
+// even though some of those runes now take more than one UTF-8 byte to
+// encode. Callers must force WIDTH_UNIT_RUNES semantics on fixed-width
+// schemas when the transcoded flag comes back true, since WIDTH_UNIT_BYTES
+// offsets no longer correspond to the source encoding's columns.
+func decodeToUTF8(data []byte) (string, bool) {
+	if utf8.Valid(data) {
+		return string(data), false
+	}
+
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			runes[i] = windows1252Table[b-0x80]
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+
+	return string(runes), true
+}
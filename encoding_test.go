@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+// TestDecodeToUTF8ValidUTF8 checks that already-valid UTF-8 input passes
+// through unchanged and is reported as not transcoded.
+func TestDecodeToUTF8ValidUTF8(t *testing.T) {
+	decoded, transcoded := decodeToUTF8([]byte("hello"))
+	if decoded != "hello" || transcoded {
+		t.Fatalf("decodeToUTF8(%q) = (%q, %v), want (%q, false)", "hello", decoded, transcoded, "hello")
+	}
+}
+
+// TestDecodeToUTF8PreservesRuneCount checks the invariant that
+// forceRuneWidths relies on: transcoding Latin-1/Windows-1252 input
+// produces exactly one output rune per input byte, even when that rune
+// takes more than one byte to encode as UTF-8 (e.g. "é" from 0xE9).
+func TestDecodeToUTF8PreservesRuneCount(t *testing.T) {
+	latin1 := []byte("caf\xE9XY")
+
+	decoded, transcoded := decodeToUTF8(latin1)
+	if !transcoded {
+		t.Fatalf("decodeToUTF8(%v): want transcoded=true", latin1)
+	}
+	if got := utf8.RuneCountInString(decoded); got != len(latin1) {
+		t.Fatalf("decodeToUTF8(%v) rune count = %d, want %d (one per input byte)", latin1, got, len(latin1))
+	}
+	if len(decoded) == len(latin1) {
+		t.Fatalf("decodeToUTF8(%v): expected the byte length to grow once transcoded, stayed at %d", latin1, len(decoded))
+	}
+}
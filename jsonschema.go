@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// JsonSchemaProperty describes the subset of JSON Schema field-level
+// constraints this processor understands. Enum is typed as []interface{}
+// rather than []string because JSON Schema allows an enum of any JSON
+// value (e.g. integers on an INTEGER field); validateRecord compares
+// against it with fmt.Sprintf so a non-string enum still validates instead
+// of failing to even parse the sidecar.
+type JsonSchemaProperty struct {
+	Type string        `json:"type"`
+	Enum []interface{} `json:"enum"`
+}
+
+// JsonSchema is a minimal JSON Schema document covering the keywords needed
+// to validate an assembled record before it is POSTed: which fields are
+// required, and which fields are constrained to an enumerated set of values.
+type JsonSchema struct {
+	Properties map[string]JsonSchemaProperty `json:"properties"`
+	Required   []string                      `json:"required"`
+}
+
+// getJsonSchemaForSchema loads the optional "<base>.schema.json" sidecar for
+// a schema file, returning a nil schema (and no error) if no sidecar exists.
+func getJsonSchemaForSchema(source Source, schemaFileName string) (*JsonSchema, error) {
+	dotIndex := strings.LastIndex(schemaFileName, ".")
+	if dotIndex == -1 {
+		return nil, fmt.Errorf("schema filename %q has no extension", schemaFileName)
+	}
+
+	baseName := schemaFileName[:dotIndex]
+	schemaFile, openErr := source.Open("schemas/" + baseName + ".schema.json")
+	if openErr != nil {
+		if errors.Is(openErr, errSourceFileNotFound) {
+			return nil, nil
+		}
+		return nil, openErr
+	}
+	defer schemaFile.Close()
+
+	contents, readErr := ioutil.ReadAll(schemaFile)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var jsonSchema JsonSchema
+	if unmarshalErr := json.Unmarshal(contents, &jsonSchema); unmarshalErr != nil {
+		return nil, fmt.Errorf("parsing JSON schema %s.schema.json: %w", baseName, unmarshalErr)
+	}
+
+	return &jsonSchema, nil
+}
+
+// validateRecord checks an assembled record against jsonSchema's required
+// and enum constraints, returning a single error describing every violation
+// found.
+func validateRecord(data map[string]interface{}, jsonSchema *JsonSchema) error {
+	var violations []string
+
+	for _, requiredField := range jsonSchema.Required {
+		if _, ok := data[requiredField]; !ok {
+			violations = append(violations, fmt.Sprintf("missing required field %q", requiredField))
+		}
+	}
+
+	for fieldName, property := range jsonSchema.Properties {
+		if len(property.Enum) == 0 {
+			continue
+		}
+
+		value, ok := data[fieldName]
+		if !ok || value == nil {
+			continue
+		}
+
+		valueStr := fmt.Sprintf("%v", value)
+		allowed := false
+		for _, enumVal := range property.Enum {
+			if valueStr == fmt.Sprintf("%v", enumVal) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("field %q: value %q is not one of %v", fieldName, valueStr, property.Enum))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("schema validation failed: %s", strings.Join(violations, "; "))
+	}
+
+	return nil
+}
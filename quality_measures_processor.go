@@ -2,15 +2,13 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -18,109 +16,279 @@ import (
 const (
 	API_URL = "https://2swdepm0wa.execute-api.us-east-1.amazonaws.com/prod/NavaInterview/measures"
 
-	CSV_NAME_INDEX      = 0
-	CSV_WIDTH_INDEX     = 1
-	CSV_DATA_TYPE_INDEX = 2
+	CSV_NAME_INDEX       = 0
+	CSV_WIDTH_INDEX      = 1
+	CSV_DATA_TYPE_INDEX  = 2
+	CSV_FORMAT_INDEX     = 3
+	CSV_NULLABLE_INDEX   = 4
+	CSV_WIDTH_UNIT_INDEX = 5
 
 	DATA_TYPE_TEXT    = "TEXT"
 	DATA_TYPE_INTEGER = "INTEGER"
 	DATA_TYPE_BOOLEAN = "BOOLEAN"
+	DATA_TYPE_FLOAT   = "FLOAT"
+	DATA_TYPE_DATE    = "DATE"
+
+	// NULLABLE_MARKER is the value of the optional CSV_NULLABLE_INDEX column
+	// that marks a field as nullable.
+	NULLABLE_MARKER = "NULLABLE"
+
+	// WIDTH_UNIT_BYTES and WIDTH_UNIT_RUNES are the valid values of the
+	// optional CSV_WIDTH_UNIT_INDEX column. WIDTH_UNIT_BYTES is assumed when
+	// the column is absent, preserving the original behavior.
+	WIDTH_UNIT_BYTES = "bytes"
+	WIDTH_UNIT_RUNES = "runes"
+
+	// SCHEMA_UTF8_BOM is the leading byte sequence some schema CSVs carry
+	// when saved as "UTF-8 with BOM"; it is stripped before parsing.
+	SCHEMA_UTF8_BOM = "\xEF\xBB\xBF"
+
+	// SCHEMA_COMMENT_PREFIX marks a schema CSV line as a comment to be
+	// skipped rather than parsed as a field definition.
+	SCHEMA_COMMENT_PREFIX = "#"
+
+	// DEFAULT_MAX_LINE_BYTES bounds how long a single data record may be
+	// before getData gives up rather than silently truncating it.
+	DEFAULT_MAX_LINE_BYTES = 1024 * 1024
 )
 
 type Field struct {
 	Name  string
 	Width int
 	Type  string
+
+	// Format is the time.Parse layout to use for DATA_TYPE_DATE fields. If
+	// empty, "2006-01-02" is assumed.
+	Format string
+
+	// Nullable marks a field whose all-whitespace raw value should be
+	// treated as a null JSON value rather than a parse error.
+	Nullable bool
+
+	// WidthUnit selects how Width is measured for FixedWidthHandler
+	// columns: WIDTH_UNIT_BYTES (default) or WIDTH_UNIT_RUNES, which
+	// decodes Width runes instead of Width bytes so multi-byte UTF-8 data
+	// doesn't get mis-sliced.
+	WidthUnit string
 }
 
 func main() {
+	sourceFlag := flag.String("source", "", "where to read schemas/ and data/ from: a directory path (default \".\"), "+
+		"\"zip:<path-or-url>\" for a zip bundle, or a bare http(s) URL for a remote zip bundle")
+	zipPasswordFlag := flag.String("zip-password", "", "password for a ZipCrypto-protected zip bundle passed via --source")
+	maxLineBytesFlag := flag.Int("max-line-bytes", DEFAULT_MAX_LINE_BYTES, "maximum length in bytes of a single data record")
+	flag.Parse()
+
+	source, sourceErr := resolveSource(*sourceFlag, *zipPasswordFlag)
+	if sourceErr != nil {
+		log.Panic(sourceErr)
+	}
+
 	// Get the list of schema files
-	schemaFileNames, err := getSchemaFilenames()
+	schemaFileNames, err := getSchemaFilenames(source)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	for _, fileName := range schemaFileNames {
-		// Get the schema information from the file
-		schema, schemaErr := getSchema(fileName)
-		if schemaErr != nil {
-			log.Printf("Skipping schema file %s", fileName)
-			continue
-		}
+	// Start the upload worker pool, and feed it records on a separate
+	// goroutine so summarizeResults can drain the results channel as
+	// uploads complete rather than after every record has been parsed.
+	uploader := NewUploader(DEFAULT_WORKER_COUNT, DEFAULT_BATCH_SIZE, DEFAULT_FLUSH_INTERVAL)
+	uploader.Start()
 
-		// Construct the data file name from the schema file name and load the records
-		dotIndex := strings.LastIndex(fileName, ".")
-		if dotIndex == -1 {
-			log.Printf("Found schema filename with no '.': %s. Skipping...", fileName)
-			continue
-		}
-		dataFileName := fileName[0:dotIndex] + ".txt"
-		dataRecords, dataErr := getData(dataFileName)
-		if dataErr != nil {
-			log.Printf("Skipping data file %s", fileName)
-			continue
-		}
+	go func() {
+		for _, fileName := range schemaFileNames {
+			// Get the schema information from the file
+			schema, schemaErr := getSchema(source, fileName)
+			if schemaErr != nil {
+				log.Printf("Skipping schema file %s", fileName)
+				continue
+			}
+
+			// Pick the handler for this schema, so the data file extension
+			// is determined by format rather than hard-coded to fixed-width
+			handler, handlerErr := getHandlerForSchema(source, fileName)
+			if handlerErr != nil {
+				log.Printf("Skipping schema file %s: %+v", fileName, handlerErr)
+				continue
+			}
 
-		// Iterate over the records from the file, parse & convert them to JSON, and post to the API
-		for _, dataRecord := range dataRecords {
-			recordJson, jsonErr := getRecordJson(dataRecord, schema)
-			if jsonErr != nil {
-				log.Printf("Skipping data record '%s'", dataRecord)
+			// Load the optional JSON Schema sidecar used to validate records
+			// beyond what the Field types themselves express
+			jsonSchema, jsonSchemaErr := getJsonSchemaForSchema(source, fileName)
+			if jsonSchemaErr != nil {
+				log.Printf("Skipping schema file %s: %+v", fileName, jsonSchemaErr)
 				continue
 			}
 
-			postRecord(recordJson)
+			// Construct the data file name from the schema file name and load the records
+			dotIndex := strings.LastIndex(fileName, ".")
+			if dotIndex == -1 {
+				log.Printf("Found schema filename with no '.': %s. Skipping...", fileName)
+				continue
+			}
+			dataFileName := fileName[0:dotIndex] + "." + handler.Extension()
+			dataRecords, transcoded, dataErr := getData(source, dataFileName, *maxLineBytesFlag)
+			if dataErr != nil {
+				log.Printf("Skipping data file %s", fileName)
+				continue
+			}
+
+			// A data file that had to be transcoded from a non-UTF-8 encoding no
+			// longer has byte offsets that match its original columns, since some
+			// bytes became multi-byte UTF-8 runes; force WIDTH_UNIT_RUNES so
+			// fixed-width columns still line up with the original single-byte-per-
+			// column layout.
+			effectiveSchema := schema
+			if transcoded {
+				effectiveSchema = forceRuneWidths(schema)
+			}
+
+			// Iterate over the records from the file, parse & convert them to JSON, and submit for upload
+			for i, dataRecord := range dataRecords {
+				recordJson, jsonErr := getRecordJson(handler, i+1, dataRecord, effectiveSchema, jsonSchema)
+				if jsonErr != nil {
+					log.Printf("Skipping data record '%s': %+v", dataRecord, jsonErr)
+					continue
+				}
+
+				uploader.Submit(recordJson)
+			}
 		}
-	}
+
+		uploader.Close()
+	}()
+
+	summarizeResults(uploader.Results())
 }
 
-// getSchemaFilenames returns a list of names of files in the schemas directory
-func getSchemaFilenames() ([]string, error) {
-	files, err := ioutil.ReadDir("schemas")
+// schemaSidecarSuffixes lists the sidecar files that live alongside a schema
+// in the schemas/ directory but are not themselves schemas: the ".handler"
+// data handler selector, the ".meta.json" CSV parsing metadata, and the
+// ".schema.json" JSON Schema validation sidecar.
+var schemaSidecarSuffixes = []string{".handler", ".meta.json", ".schema.json"}
+
+// getSchemaFilenames returns a list of names of schema files in the source's schemas directory,
+// excluding known sidecar files (see schemaSidecarSuffixes) so they aren't mistakenly parsed as
+// schemas in their own right.
+func getSchemaFilenames(source Source) ([]string, error) {
+	fileNames, err := source.List("schemas")
 	if err != nil {
 		log.Printf("Got error attempting to list schema files: %+v", err)
 		return nil, err
 	}
 
-	fileNames := make([]string, len(files))
-	for i, file := range files {
-		fileNames[i] = file.Name()
+	schemaFileNames := make([]string, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		if isSchemaSidecar(fileName) {
+			continue
+		}
+		schemaFileNames = append(schemaFileNames, fileName)
 	}
 
-	return fileNames, nil
+	return schemaFileNames, nil
 }
 
-// getSchema is a helper function for parsing a CSV schema file and returning the records as a list.
-func getSchema(fileName string) ([]Field, error) {
-	csvFile, openErr := os.Open("schemas/" + fileName)
+// isSchemaSidecar reports whether fileName is a known sidecar rather than a
+// schema file in its own right.
+func isSchemaSidecar(fileName string) bool {
+	for _, suffix := range schemaSidecarSuffixes {
+		if strings.HasSuffix(fileName, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getSchema is a helper function for parsing a CSV schema file and returning the records as a list. It tolerates a
+// leading UTF-8 BOM, "#"-prefixed comment lines, a blank line, and an optional header row (detected by checking
+// whether the first row's width column parses as an integer).
+func getSchema(source Source, fileName string) ([]Field, error) {
+	schemaFile, openErr := source.Open("schemas/" + fileName)
 	if openErr != nil {
 		log.Printf("Got error opening CSV file %s: %+v", fileName, openErr)
 		return nil, openErr
 	}
+	defer schemaFile.Close()
+
+	contentBytes, readErr := ioutil.ReadAll(schemaFile)
+	if readErr != nil {
+		log.Printf("Got error reading CSV file %s: %+v", fileName, readErr)
+		return nil, readErr
+	}
 
-	// Close the file when we're done
-	defer csvFile.Close()
+	delimiter, delimiterErr := getSchemaDelimiter(source, fileName)
+	if delimiterErr != nil {
+		log.Printf("Got error reading schema metadata for %s: %+v", fileName, delimiterErr)
+		return nil, delimiterErr
+	}
+
+	content := strings.TrimPrefix(string(contentBytes), SCHEMA_UTF8_BOM)
+
+	// csvLineNumbers records each kept line's 1-based line number in the original file, so errors can name it even
+	// after comment and blank lines have been dropped.
+	csvLines := make([]string, 0, strings.Count(content, "\n")+1)
+	csvLineNumbers := make([]int, 0, cap(csvLines))
+	for lineIndex, line := range strings.Split(content, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, SCHEMA_COMMENT_PREFIX) {
+			continue
+		}
+		csvLines = append(csvLines, line)
+		csvLineNumbers = append(csvLineNumbers, lineIndex+1)
+	}
+
+	csvReader := csv.NewReader(strings.NewReader(strings.Join(csvLines, "\n")))
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1
 
-	csvReader := csv.NewReader(bufio.NewReader(csvFile))
 	records, parseErr := csvReader.ReadAll()
 	if parseErr != nil {
 		log.Printf("Got error parsing CSV file %s: %+v", fileName, parseErr)
 		return nil, parseErr
 	}
+	if len(records) == 0 {
+		errMsg := fmt.Sprintf("CSV file %s has no field definitions", fileName)
+		log.Printf(errMsg)
+		return nil, errors.New(errMsg)
+	}
+
+	startIndex := 0
+	if _, headerErr := strconv.Atoi(strings.TrimSpace(records[0][CSV_WIDTH_INDEX])); headerErr != nil {
+		// The first row's width column isn't an integer, so treat it as a header row and skip it.
+		startIndex = 1
+	}
+
+	expectedColumns := len(records[startIndex])
+	fields := make([]Field, 0, len(records)-startIndex)
+	for i := startIndex; i < len(records); i++ {
+		record := records[i]
+		lineNumber := csvLineNumbers[i]
+
+		if len(record) != expectedColumns {
+			errMsg := fmt.Sprintf("CSV file %s, line %d: expected %d columns, got %d", fileName, lineNumber, expectedColumns, len(record))
+			log.Printf(errMsg)
+			return nil, errors.New(errMsg)
+		}
 
-	fields := make([]Field, len(records))
-	for i, record := range records {
 		// Parse width as int
 		width, err := strconv.Atoi(strings.TrimSpace(record[CSV_WIDTH_INDEX]))
 		if err != nil {
-			log.Printf("Found invalid integer value for width in CSV file %s, record %d: '%s'", fileName, i+1, record[1])
+			log.Printf("Found invalid integer value for width in CSV file %s, line %d: '%s'", fileName, lineNumber, record[CSV_WIDTH_INDEX])
 			return nil, err
 		}
+		if width < 0 {
+			errMsg := fmt.Sprintf("CSV file %s, line %d: width cannot be negative: %d", fileName, lineNumber, width)
+			log.Printf(errMsg)
+			return nil, errors.New(errMsg)
+		}
 
 		// Validate data type
 		dataType := record[CSV_DATA_TYPE_INDEX]
-		if (dataType != DATA_TYPE_BOOLEAN) && (dataType != DATA_TYPE_INTEGER) && (dataType != DATA_TYPE_TEXT) {
-			errMsg := fmt.Sprintf("Found invalid value for data type in CSV file %s, record %d: '%s'", fileName, i+1, dataType)
+		if (dataType != DATA_TYPE_BOOLEAN) && (dataType != DATA_TYPE_INTEGER) && (dataType != DATA_TYPE_TEXT) &&
+			(dataType != DATA_TYPE_FLOAT) && (dataType != DATA_TYPE_DATE) {
+			errMsg := fmt.Sprintf("Found invalid value for data type in CSV file %s, line %d: '%s'", fileName, lineNumber, dataType)
 			log.Printf(errMsg)
 			return nil, errors.New(errMsg)
 		}
@@ -130,65 +298,84 @@ func getSchema(fileName string) ([]Field, error) {
 			Width: width,
 			Type:  dataType,
 		}
-		fields[i] = field
+
+		// The format, nullable, and width unit columns are optional trailing
+		// columns, present only in schemas that need them.
+		if len(record) > CSV_FORMAT_INDEX {
+			field.Format = strings.TrimSpace(record[CSV_FORMAT_INDEX])
+		}
+		if len(record) > CSV_NULLABLE_INDEX {
+			field.Nullable = strings.EqualFold(strings.TrimSpace(record[CSV_NULLABLE_INDEX]), NULLABLE_MARKER)
+		}
+		if len(record) > CSV_WIDTH_UNIT_INDEX {
+			widthUnit := strings.ToLower(strings.TrimSpace(record[CSV_WIDTH_UNIT_INDEX]))
+			if widthUnit != "" && widthUnit != WIDTH_UNIT_BYTES && widthUnit != WIDTH_UNIT_RUNES {
+				errMsg := fmt.Sprintf("CSV file %s, line %d: invalid width unit '%s'", fileName, lineNumber, widthUnit)
+				log.Printf(errMsg)
+				return nil, errors.New(errMsg)
+			}
+			field.WidthUnit = widthUnit
+		}
+
+		fields = append(fields, field)
 	}
 
 	return fields, nil
 }
 
-// getData reads the specified data file and returns the records as a list of strings
-func getData(fileName string) ([]string, error) {
-	dataFile, openErr := os.Open("data/" + fileName)
+// getData reads the specified data file and returns the records as a list of strings, along with whether the file
+// had to be transcoded from a non-UTF-8 encoding (e.g. Latin-1/Windows-1252) to get there. maxLineBytes bounds how
+// long a single record may be before Scan fails instead of silently truncating it.
+func getData(source Source, fileName string, maxLineBytes int) ([]string, bool, error) {
+	dataFile, openErr := source.Open("data/" + fileName)
 	if openErr != nil {
 		log.Printf("Got error opening data file %s: %+v", fileName, openErr)
-		return nil, openErr
+		return nil, false, openErr
 	}
-
-	// Close the file when we're done
 	defer dataFile.Close()
 
+	contentBytes, readErr := ioutil.ReadAll(dataFile)
+	if readErr != nil {
+		log.Printf("Got error reading data file %s: %+v", fileName, readErr)
+		return nil, false, readErr
+	}
+
+	content, transcoded := decodeToUTF8(contentBytes)
+
 	// Read the file line-by-line
 	records := make([]string, 0, 10)
-	fileReader := bufio.NewScanner(dataFile)
+	fileReader := bufio.NewScanner(strings.NewReader(content))
+	initialBufferSize := bufio.MaxScanTokenSize
+	if maxLineBytes < initialBufferSize {
+		initialBufferSize = maxLineBytes
+	}
+	fileReader.Buffer(make([]byte, 0, initialBufferSize), maxLineBytes)
 	for fileReader.Scan() {
 		records = append(records, fileReader.Text())
 	}
-	if readErr := fileReader.Err(); readErr != nil {
-		log.Printf("Got error reading data file %s: %+v", fileName, readErr)
-		return records, readErr
+	if scanErr := fileReader.Err(); scanErr != nil {
+		log.Printf("Got error reading data file %s: %+v", fileName, scanErr)
+		return records, transcoded, scanErr
 	}
 
-	return records, nil
+	return records, transcoded, nil
 }
 
-// getRecordJson parses a record from the data file using the specified schema and converts it to a JSON-formatted string
-func getRecordJson(dataRecord string, schema []Field) ([]byte, error) {
-	data := make(map[string]interface{})
+// getRecordJson parses a record from the data file using the given handler and schema, validates it against the
+// optional JSON schema, and converts it to a JSON-formatted string. recordNumber (1-based) is folded into any
+// parsing error to identify which record failed.
+func getRecordJson(handler DataHandler, recordNumber int, dataRecord string, schema []Field, jsonSchema *JsonSchema) ([]byte, error) {
+	data, parseErr := handler.ParseRecord(recordNumber, dataRecord, schema)
+	if parseErr != nil {
+		log.Printf("Got error parsing record: %+v", parseErr)
+		return nil, parseErr
+	}
 
-	startIndex := 0
-	for _, field := range schema {
-		fieldValue := strings.TrimSpace(dataRecord[startIndex:(startIndex + field.Width)])
-		switch field.Type {
-		case DATA_TYPE_BOOLEAN:
-			boolVal, boolErr := strconv.ParseBool(fieldValue)
-			if boolErr != nil {
-				log.Printf("Found invalid boolean value: %s", fieldValue)
-				return nil, boolErr
-			}
-			data[field.Name] = boolVal
-		case DATA_TYPE_INTEGER:
-			intVal, intErr := strconv.Atoi(fieldValue)
-			if intErr != nil {
-				log.Printf("Found invalid integer value: %s", fieldValue)
-				return nil, intErr
-			}
-			data[field.Name] = intVal
-		case DATA_TYPE_TEXT:
-			data[field.Name] = fieldValue
+	if jsonSchema != nil {
+		if validationErr := validateRecord(data, jsonSchema); validationErr != nil {
+			log.Printf("Record failed schema validation: %+v", validationErr)
+			return nil, validationErr
 		}
-
-		// Increment the index to the start of the next field in the record
-		startIndex += field.Width
 	}
 
 	jsonData, jsonErr := json.Marshal(data)
@@ -197,21 +384,3 @@ func getRecordJson(dataRecord string, schema []Field) ([]byte, error) {
 	}
 	return jsonData, jsonErr
 }
-
-// postRecord sends the input JSON data to the API in a POST request
-func postRecord(jsonRecord []byte) {
-	log.Printf("POSTing JSON data to API: %s", string(jsonRecord))
-	resp, postErr := http.Post(API_URL, "application/json", bytes.NewBuffer(jsonRecord))
-
-	if postErr != nil {
-		log.Printf("Received error response from API: %+v", postErr)
-	} else {
-		defer resp.Body.Close()
-		body, respErr := ioutil.ReadAll(resp.Body)
-		if respErr != nil {
-			log.Printf("Got error parsing API response: %+v", respErr)
-		} else {
-			log.Printf("Received %d response from API: %s", resp.StatusCode, string(body))
-		}
-	}
-}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestIsSchemaSidecar checks that every known sidecar suffix is recognized
+// and a plain schema filename is not.
+func TestIsSchemaSidecar(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     bool
+	}{
+		{fileName: "foo.csv", want: false},
+		{fileName: "foo.handler", want: true},
+		{fileName: "foo.meta.json", want: true},
+		{fileName: "foo.schema.json", want: true},
+	}
+
+	for _, tc := range tests {
+		if got := isSchemaSidecar(tc.fileName); got != tc.want {
+			t.Errorf("isSchemaSidecar(%q) = %v, want %v", tc.fileName, got, tc.want)
+		}
+	}
+}
+
+// TestGetSchemaFilenamesExcludesSidecars is a regression test: a schemas/
+// directory with a schema plus its .handler, .meta.json, and .schema.json
+// sidecars should yield only the schema itself, not every sidecar treated
+// as its own (invalid) schema.
+func TestGetSchemaFilenamesExcludesSidecars(t *testing.T) {
+	root := t.TempDir()
+	schemasDir := filepath.Join(root, "schemas")
+	if err := os.MkdirAll(schemasDir, 0o755); err != nil {
+		t.Fatalf("creating schemas dir: %v", err)
+	}
+
+	for _, name := range []string{"foo.csv", "foo.handler", "foo.meta.json", "foo.schema.json"} {
+		if err := ioutil.WriteFile(filepath.Join(schemasDir, name), []byte("irrelevant"), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	fileNames, err := getSchemaFilenames(DirSource{Root: root})
+	if err != nil {
+		t.Fatalf("getSchemaFilenames returned error: %v", err)
+	}
+
+	sort.Strings(fileNames)
+	want := []string{"foo.csv"}
+	if !reflect.DeepEqual(fileNames, want) {
+		t.Fatalf("getSchemaFilenames = %v, want %v", fileNames, want)
+	}
+}
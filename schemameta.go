@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// schemaMeta is the optional "<base>.meta.json" sidecar that configures how
+// a schema's CSV is parsed.
+type schemaMeta struct {
+	Delimiter string `json:"delimiter"`
+}
+
+// getSchemaDelimiter returns the CSV field delimiter for a schema file, read
+// from its "<base>.meta.json" sidecar if one exists. Delimiter may be the
+// words "comma" (default), "tab", or "semicolon", or a literal single
+// character.
+func getSchemaDelimiter(source Source, schemaFileName string) (rune, error) {
+	dotIndex := strings.LastIndex(schemaFileName, ".")
+	if dotIndex == -1 {
+		return ',', nil
+	}
+
+	baseName := schemaFileName[:dotIndex]
+	metaFile, openErr := source.Open("schemas/" + baseName + ".meta.json")
+	if openErr != nil {
+		if errors.Is(openErr, errSourceFileNotFound) {
+			return ',', nil
+		}
+		return 0, openErr
+	}
+	defer metaFile.Close()
+
+	contents, readErr := ioutil.ReadAll(metaFile)
+	if readErr != nil {
+		return 0, readErr
+	}
+
+	var meta schemaMeta
+	if unmarshalErr := json.Unmarshal(contents, &meta); unmarshalErr != nil {
+		return 0, fmt.Errorf("parsing schema metadata %s.meta.json: %w", baseName, unmarshalErr)
+	}
+
+	switch strings.ToLower(meta.Delimiter) {
+	case "", "comma":
+		return ',', nil
+	case "tab":
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	default:
+		delimiterRunes := []rune(meta.Delimiter)
+		if len(delimiterRunes) != 1 {
+			return 0, fmt.Errorf("schema metadata %s.meta.json: delimiter %q is not a single character", baseName, meta.Delimiter)
+		}
+		return delimiterRunes[0], nil
+	}
+}
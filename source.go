@@ -0,0 +1,235 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// errSourceFileNotFound is returned by a Source's internal lookup when the
+// requested file doesn't exist, so openWithGzipFallback can tell "missing"
+// apart from a real read error and fall back to a ".gz" copy.
+var errSourceFileNotFound = errors.New("file not found in source")
+
+// Source abstracts over where schema/data files live, so main can treat a
+// local directory, a zip bundle, or an HTTP(S)-hosted zip bundle identically.
+type Source interface {
+	// List returns the names of files directly under dir (e.g. "schemas").
+	List(dir string) ([]string, error)
+	// Open returns a reader for the named file (e.g. "schemas/foo.csv"). A
+	// file stored as "<name>.gz" is transparently gunzipped. Callers must
+	// Close the returned reader.
+	Open(name string) (io.ReadCloser, error)
+}
+
+// resolveSource builds a Source from a --source flag value: "" or "."
+// means the current directory, "zip:<path-or-url>" means a zip bundle
+// (local path or HTTP(S) URL), a bare http(s) URL is shorthand for the same,
+// and anything else is treated as a local directory path. zipPassword is
+// used to decrypt password-protected entries in a zip bundle; it is ignored
+// for a plain directory source.
+func resolveSource(spec string, zipPassword string) (Source, error) {
+	switch {
+	case spec == "" || spec == ".":
+		return DirSource{Root: "."}, nil
+	case strings.HasPrefix(spec, "zip:"):
+		return newZipSource(strings.TrimPrefix(spec, "zip:"), zipPassword)
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return newZipSource(spec, zipPassword)
+	default:
+		return DirSource{Root: spec}, nil
+	}
+}
+
+// openWithGzipFallback tries name directly, then "<name>.gz" (transparently
+// gunzipped), so a data file may be shipped compressed without callers
+// needing to know.
+func openWithGzipFallback(rawOpen func(string) (io.ReadCloser, error), name string) (io.ReadCloser, error) {
+	reader, err := rawOpen(name)
+	if err == nil {
+		return reader, nil
+	}
+	if !errors.Is(err, errSourceFileNotFound) {
+		return nil, err
+	}
+
+	gzName := name + ".gz"
+	raw, gzErr := rawOpen(gzName)
+	if gzErr != nil {
+		return nil, gzErr
+	}
+
+	gzipReader, newReaderErr := gzip.NewReader(raw)
+	if newReaderErr != nil {
+		raw.Close()
+		return nil, fmt.Errorf("opening gzip file %s: %w", gzName, newReaderErr)
+	}
+
+	return gzipReadCloser{Reader: gzipReader, raw: raw}, nil
+}
+
+// gzipReadCloser closes both the gzip.Reader and the underlying raw reader
+// it was wrapping.
+type gzipReadCloser struct {
+	*gzip.Reader
+	raw io.ReadCloser
+}
+
+func (g gzipReadCloser) Close() error {
+	gzipErr := g.Reader.Close()
+	rawErr := g.raw.Close()
+	if gzipErr != nil {
+		return gzipErr
+	}
+	return rawErr
+}
+
+// DirSource reads schema/data files from a local directory tree.
+type DirSource struct {
+	Root string
+}
+
+func (d DirSource) rawOpen(name string) (io.ReadCloser, error) {
+	file, err := os.Open(path.Join(d.Root, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errSourceFileNotFound
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (d DirSource) Open(name string) (io.ReadCloser, error) {
+	return openWithGzipFallback(d.rawOpen, name)
+}
+
+func (d DirSource) List(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(path.Join(d.Root, dir))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name()
+	}
+
+	return names, nil
+}
+
+// ZipSource reads schema/data files out of a zip bundle, which may have been
+// loaded from a local file or downloaded from an HTTP(S) URL. password, if
+// set, is used to decrypt entries marked as traditionally (ZipCrypto)
+// encrypted.
+type ZipSource struct {
+	closer   io.Closer
+	entries  map[string]*zip.File
+	password string
+}
+
+// newZipSource opens a zip bundle from a local path or an HTTP(S) URL.
+func newZipSource(location string, password string) (*ZipSource, error) {
+	var (
+		readerAt io.ReaderAt
+		size     int64
+		closer   io.Closer
+	)
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		resp, getErr := http.Get(location)
+		if getErr != nil {
+			return nil, fmt.Errorf("fetching zip bundle %s: %w", location, getErr)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("fetching zip bundle %s: unexpected status %d", location, resp.StatusCode)
+		}
+
+		body, readErr := ioutil.ReadAll(resp.Body)
+		if readErr != nil {
+			return nil, fmt.Errorf("downloading zip bundle %s: %w", location, readErr)
+		}
+
+		readerAt = bytes.NewReader(body)
+		size = int64(len(body))
+	} else {
+		file, openErr := os.Open(location)
+		if openErr != nil {
+			return nil, fmt.Errorf("opening zip bundle %s: %w", location, openErr)
+		}
+
+		info, statErr := file.Stat()
+		if statErr != nil {
+			file.Close()
+			return nil, fmt.Errorf("statting zip bundle %s: %w", location, statErr)
+		}
+
+		readerAt = file
+		size = info.Size()
+		closer = file
+	}
+
+	zipReader, zipErr := zip.NewReader(readerAt, size)
+	if zipErr != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("reading zip bundle %s: %w", location, zipErr)
+	}
+
+	entries := make(map[string]*zip.File, len(zipReader.File))
+	for _, entry := range zipReader.File {
+		entries[entry.Name] = entry
+	}
+
+	return &ZipSource{closer: closer, entries: entries, password: password}, nil
+}
+
+func (z *ZipSource) rawOpen(name string) (io.ReadCloser, error) {
+	entry, ok := z.entries[name]
+	if !ok {
+		return nil, errSourceFileNotFound
+	}
+
+	// Bit 0 of the general-purpose flag marks the entry as encrypted.
+	if entry.Flags&0x1 != 0 {
+		return openEncryptedZipEntry(entry, z.password)
+	}
+
+	return entry.Open()
+}
+
+func (z *ZipSource) Open(name string) (io.ReadCloser, error) {
+	return openWithGzipFallback(z.rawOpen, name)
+}
+
+func (z *ZipSource) List(dir string) ([]string, error) {
+	prefix := dir + "/"
+
+	var names []string
+	for name := range z.entries {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(name, prefix)
+		if rest == "" || strings.Contains(rest, "/") {
+			continue
+		}
+
+		names = append(names, rest)
+	}
+
+	return names, nil
+}
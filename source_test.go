@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBundleBase64 is a real zip bundle (built with the system `zip` tool,
+// not archive/zip) containing:
+//   - schemas/a.csv: unencrypted, stored
+//   - data/c.txt.gz: unencrypted, gzip-compressed-then-stored, to exercise
+//     the ".gz" fallback when callers ask for "data/c.txt"
+//   - schemas/b.csv: ZipCrypto-encrypted (password "secret123"), stored
+const testBundleBase64 = "UEsDBAoAAAAAADVB+1wE+xx4GwAAABsAAAANAAAAc2NoZW1hcy9hLmNzdm5hbWUsd2lkdGgsdHlw" +
+	"ZQpmb28sMyxURVhUClBLAwQKAAAAAAA1Qftctkn+MisAAAArAAAADQAAAGRhdGEvYy50eHQuZ3of" +
+	"iwgIRhJnagADYy50eHQAy0jNyclXSK/KLFAozy/KSeECAD5WAOgRAAAAUEsDBAoACQAAADVB+1zq" +
+	"TKJNGgAAAA4AAAANAAAAc2NoZW1hcy9iLmNzdkD8Y0S6sNB1oSeYy0rJs0fjmFURwzxxl0hoUEsH" +
+	"COpMok0aAAAADgAAAFBLAQIeAwoAAAAAADVB+1wE+xx4GwAAABsAAAANAAAAAAAAAAEAAACkgQAA" +
+	"AABzY2hlbWFzL2EuY3N2UEsBAh4DCgAAAAAANUH7XLZJ/jIrAAAAKwAAAA0AAAAAAAAAAAAAAKSB" +
+	"RgAAAGRhdGEvYy50eHQuZ3pQSwECHgMKAAkAAAA1Qftc6kyiTRoAAAAOAAAADQAAAAAAAAABAAAA" +
+	"pIGcAAAAc2NoZW1hcy9iLmNzdlBLBQYAAAAAAwADALEAAADxAAAAAAA="
+
+// writeTestBundle decodes testBundleBase64 to a temp file and returns its
+// path, which newZipSource needs since it opens a zip by path or URL.
+func writeTestBundle(t *testing.T) string {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(testBundleBase64)
+	if err != nil {
+		t.Fatalf("decoding test bundle: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := ioutil.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing test bundle: %v", err)
+	}
+
+	return path
+}
+
+// TestZipSourceListAndOpen checks that List finds entries under a directory
+// prefix and Open reads an unencrypted entry's contents.
+func TestZipSourceListAndOpen(t *testing.T) {
+	source, err := newZipSource(writeTestBundle(t), "")
+	if err != nil {
+		t.Fatalf("newZipSource returned error: %v", err)
+	}
+
+	names, err := source.List("schemas")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	want := map[string]bool{"a.csv": true, "b.csv": true}
+	if len(names) != len(want) {
+		t.Fatalf("List(\"schemas\") = %v, want keys of %v", names, want)
+	}
+	for _, name := range names {
+		if !want[name] {
+			t.Fatalf("List(\"schemas\") returned unexpected entry %q", name)
+		}
+	}
+
+	reader, err := source.Open("schemas/a.csv")
+	if err != nil {
+		t.Fatalf("Open(schemas/a.csv) returned error: %v", err)
+	}
+	defer reader.Close()
+
+	contents, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		t.Fatalf("reading schemas/a.csv: %v", readErr)
+	}
+	if string(contents) != "name,width,type\nfoo,3,TEXT\n" {
+		t.Fatalf("schemas/a.csv contents = %q", contents)
+	}
+}
+
+// TestZipSourceGzipFallback checks that Open("data/c.txt") transparently
+// falls back to the gzip-compressed "data/c.txt.gz" entry.
+func TestZipSourceGzipFallback(t *testing.T) {
+	source, err := newZipSource(writeTestBundle(t), "")
+	if err != nil {
+		t.Fatalf("newZipSource returned error: %v", err)
+	}
+
+	reader, err := source.Open("data/c.txt")
+	if err != nil {
+		t.Fatalf("Open(data/c.txt) returned error: %v", err)
+	}
+	defer reader.Close()
+
+	contents, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		t.Fatalf("reading data/c.txt: %v", readErr)
+	}
+	if string(contents) != "hello gzip world\n" {
+		t.Fatalf("data/c.txt contents = %q, want %q", contents, "hello gzip world\n")
+	}
+}
+
+// TestZipSourceEncryptedEntry checks that a ZipCrypto-encrypted entry
+// decrypts correctly with the right password and is rejected with the
+// wrong one, against a bundle built with the real `zip -P` tool (not
+// archive/zip), so the test exercises real-world ciphertext.
+func TestZipSourceEncryptedEntry(t *testing.T) {
+	bundlePath := writeTestBundle(t)
+
+	source, err := newZipSource(bundlePath, "secret123")
+	if err != nil {
+		t.Fatalf("newZipSource returned error: %v", err)
+	}
+
+	reader, err := source.Open("schemas/b.csv")
+	if err != nil {
+		t.Fatalf("Open(schemas/b.csv) with correct password returned error: %v", err)
+	}
+	defer reader.Close()
+
+	contents, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		t.Fatalf("reading schemas/b.csv: %v", readErr)
+	}
+	if string(contents) != "secret,3,TEXT\n" {
+		t.Fatalf("schemas/b.csv contents = %q, want %q", contents, "secret,3,TEXT\n")
+	}
+
+	wrongPasswordSource, err := newZipSource(bundlePath, "wrong-password")
+	if err != nil {
+		t.Fatalf("newZipSource returned error: %v", err)
+	}
+	if _, err := wrongPasswordSource.Open("schemas/b.csv"); err == nil {
+		t.Fatal("Open(schemas/b.csv) with wrong password: want error, got nil")
+	}
+
+	noPasswordSource, err := newZipSource(bundlePath, "")
+	if err != nil {
+		t.Fatalf("newZipSource returned error: %v", err)
+	}
+	if _, err := noPasswordSource.Open("schemas/b.csv"); err == nil {
+		t.Fatal("Open(schemas/b.csv) with no password: want error, got nil")
+	}
+}
+
+// TestDirSourceListAndOpen checks that the plain-directory Source lists a
+// file under a directory and opens its uncompressed contents.
+func TestDirSourceListAndOpen(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "schemas"), 0o755); err != nil {
+		t.Fatalf("creating schemas dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "schemas", "a.csv"), []byte("x,1,TEXT\n"), 0o644); err != nil {
+		t.Fatalf("writing schemas/a.csv: %v", err)
+	}
+
+	source := DirSource{Root: root}
+
+	names, err := source.List("schemas")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(names) != 1 || names[0] != "a.csv" {
+		t.Fatalf("List(\"schemas\") = %v, want [a.csv]", names)
+	}
+
+	reader, err := source.Open("schemas/a.csv")
+	if err != nil {
+		t.Fatalf("Open(schemas/a.csv) returned error: %v", err)
+	}
+	defer reader.Close()
+
+	contents, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		t.Fatalf("reading schemas/a.csv: %v", readErr)
+	}
+	if string(contents) != "x,1,TEXT\n" {
+		t.Fatalf("schemas/a.csv contents = %q", contents)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_WORKER_COUNT   = 4
+	DEFAULT_BATCH_SIZE     = 50
+	DEFAULT_FLUSH_INTERVAL = 2 * time.Second
+	DEFAULT_MAX_RETRIES    = 5
+	DEFAULT_BACKOFF_BASE   = 500 * time.Millisecond
+	DEFAULT_BACKOFF_MAX    = 30 * time.Second
+	DEAD_LETTER_FILE_NAME  = "dead_letter.jsonl"
+)
+
+// UploadResult carries the outcome of attempting to POST a single record, so
+// callers can tally successes/failures without blocking on the network call.
+type UploadResult struct {
+	Record []byte
+	Err    error
+}
+
+// Uploader batches records off of a channel and POSTs them to the API from a
+// pool of concurrent workers, retrying failed batches with exponential
+// backoff and jitter.
+type Uploader struct {
+	client        *http.Client
+	records       chan []byte
+	results       chan UploadResult
+	workerCount   int
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	wg            sync.WaitGroup
+}
+
+// NewUploader constructs an Uploader with the given concurrency, batch size,
+// and flush interval. Callers should call Start, then Submit records, then
+// Close and drain Results.
+func NewUploader(workerCount, batchSize int, flushInterval time.Duration) *Uploader {
+	return &Uploader{
+		client:        &http.Client{},
+		records:       make(chan []byte, batchSize*workerCount),
+		results:       make(chan UploadResult, batchSize*workerCount),
+		workerCount:   workerCount,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxRetries:    DEFAULT_MAX_RETRIES,
+	}
+}
+
+// Start launches the worker pool. It must be called before Submit.
+func (u *Uploader) Start() {
+	for i := 0; i < u.workerCount; i++ {
+		u.wg.Add(1)
+		go u.worker()
+	}
+
+	go func() {
+		u.wg.Wait()
+		close(u.results)
+	}()
+}
+
+// Submit enqueues a record for batching and upload.
+func (u *Uploader) Submit(record []byte) {
+	u.records <- record
+}
+
+// Close signals that no more records will be submitted. Workers flush any
+// partial batch before exiting.
+func (u *Uploader) Close() {
+	close(u.records)
+}
+
+// Results returns the channel of per-record upload outcomes. It closes once
+// all workers have exited and all batches have been flushed.
+func (u *Uploader) Results() <-chan UploadResult {
+	return u.results
+}
+
+// worker consumes records from the shared channel, accumulating them into a
+// batch that is flushed once it reaches batchSize or flushInterval elapses.
+func (u *Uploader) worker() {
+	defer u.wg.Done()
+
+	batch := make([][]byte, 0, u.batchSize)
+	ticker := time.NewTicker(u.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record, ok := <-u.records:
+			if !ok {
+				if len(batch) > 0 {
+					u.flush(batch)
+				}
+				return
+			}
+
+			batch = append(batch, record)
+			if len(batch) >= u.batchSize {
+				u.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				u.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush assembles a batch into a single JSON array payload, POSTs it with
+// retry/backoff, and reports the outcome for every record in the batch.
+func (u *Uploader) flush(batch [][]byte) {
+	payload, payloadErr := buildBatchPayload(batch)
+	if payloadErr != nil {
+		for _, record := range batch {
+			u.results <- UploadResult{Record: record, Err: payloadErr}
+		}
+		return
+	}
+
+	postErr := u.postBatchWithRetry(payload, len(batch))
+	for _, record := range batch {
+		u.results <- UploadResult{Record: record, Err: postErr}
+	}
+}
+
+// buildBatchPayload wraps a batch of already-marshaled JSON records into a
+// single JSON array, since each record's bytes are themselves valid JSON.
+func buildBatchPayload(batch [][]byte) ([]byte, error) {
+	rawRecords := make([]json.RawMessage, len(batch))
+	for i, record := range batch {
+		rawRecords[i] = record
+	}
+
+	return json.Marshal(rawRecords)
+}
+
+// postBatchWithRetry POSTs the batch payload, retrying on 5xx/429 responses
+// with exponential backoff and jitter. A Retry-After header on a 429/5xx
+// response overrides the computed backoff for that attempt.
+func (u *Uploader) postBatchWithRetry(payload []byte, recordCount int) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= u.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(u.backoffDelay(attempt, lastErr))
+		}
+
+		resp, postErr := u.client.Post(API_URL, "application/json", bytes.NewReader(payload))
+		if postErr != nil {
+			lastErr = postErr
+			log.Printf("Got error POSTing batch (attempt %d/%d): %+v", attempt+1, u.maxRetries+1, postErr)
+			continue
+		}
+
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			lastErr = readErr
+			log.Printf("Got error reading batch response body (attempt %d/%d): %+v", attempt+1, u.maxRetries+1, readErr)
+			continue
+		}
+
+		log.Printf("Received %d response from API for batch of %d records", resp.StatusCode, recordCount)
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = retryableStatusError{StatusCode: resp.StatusCode, RetryAfter: resp.Header.Get("Retry-After"), Body: string(body)}
+			continue
+		}
+
+		// Non-retryable 4xx: give up immediately.
+		return fmt.Errorf("API rejected batch with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", u.maxRetries+1, lastErr)
+}
+
+// retryableStatusError records a retryable HTTP response so backoffDelay can
+// honor a server-provided Retry-After hint.
+type retryableStatusError struct {
+	StatusCode int
+	RetryAfter string
+	Body       string
+}
+
+func (e retryableStatusError) Error() string {
+	return fmt.Sprintf("retryable status %d: %s", e.StatusCode, e.Body)
+}
+
+// backoffDelay computes the wait before the given retry attempt: it honors a
+// Retry-After header from the previous response when present, and otherwise
+// falls back to exponential backoff with full jitter.
+func (u *Uploader) backoffDelay(attempt int, lastErr error) time.Duration {
+	if retryable, ok := lastErr.(retryableStatusError); ok && retryable.RetryAfter != "" {
+		if seconds, err := strconv.Atoi(retryable.RetryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if retryAt, err := http.ParseTime(retryable.RetryAfter); err == nil {
+			if delay := time.Until(retryAt); delay > 0 {
+				return delay
+			}
+			return 0
+		}
+	}
+
+	backoff := DEFAULT_BACKOFF_BASE * time.Duration(1<<uint(attempt-1))
+	if backoff > DEFAULT_BACKOFF_MAX {
+		backoff = DEFAULT_BACKOFF_MAX
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// summarizeResults drains the upload results channel, writing every failed
+// record to the dead-letter file and logging a final success/failure count.
+func summarizeResults(results <-chan UploadResult) {
+	var succeeded, failed int
+
+	deadLetterFile, createErr := os.Create(DEAD_LETTER_FILE_NAME)
+	if createErr != nil {
+		log.Printf("Got error creating dead-letter file %s: %+v", DEAD_LETTER_FILE_NAME, createErr)
+	} else {
+		defer deadLetterFile.Close()
+	}
+
+	for result := range results {
+		if result.Err == nil {
+			succeeded++
+			continue
+		}
+
+		failed++
+		log.Printf("Failed to upload record '%s': %+v", string(result.Record), result.Err)
+
+		if deadLetterFile != nil {
+			if _, writeErr := deadLetterFile.Write(append(result.Record, '\n')); writeErr != nil {
+				log.Printf("Got error writing to dead-letter file: %+v", writeErr)
+			}
+		}
+	}
+
+	log.Printf("Upload summary: %d succeeded, %d failed", succeeded, failed)
+}
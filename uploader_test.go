@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBackoffDelayBounds checks that backoffDelay stays within the expected
+// exponential-with-jitter envelope and respects the configured cap, without
+// asserting an exact value (the delay is randomized).
+func TestBackoffDelayBounds(t *testing.T) {
+	u := NewUploader(1, 1, time.Second)
+
+	tests := []struct {
+		name    string
+		attempt int
+		maxWant time.Duration
+	}{
+		{name: "first retry", attempt: 1, maxWant: DEFAULT_BACKOFF_BASE},
+		{name: "second retry", attempt: 2, maxWant: DEFAULT_BACKOFF_BASE * 2},
+		{name: "far beyond cap", attempt: 20, maxWant: DEFAULT_BACKOFF_MAX},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			delay := u.backoffDelay(tc.attempt, nil)
+			if delay < 0 || delay > tc.maxWant {
+				t.Fatalf("backoffDelay(%d) = %v, want in [0, %v]", tc.attempt, delay, tc.maxWant)
+			}
+		})
+	}
+}
+
+// TestBackoffDelayHonorsRetryAfter checks that a numeric Retry-After value on
+// a retryableStatusError overrides the computed exponential backoff.
+func TestBackoffDelayHonorsRetryAfter(t *testing.T) {
+	u := NewUploader(1, 1, time.Second)
+
+	lastErr := retryableStatusError{StatusCode: 429, RetryAfter: "7"}
+	delay := u.backoffDelay(1, lastErr)
+	if delay != 7*time.Second {
+		t.Fatalf("backoffDelay with Retry-After=7 = %v, want 7s", delay)
+	}
+}
+
+// TestBackoffDelayIgnoresInvalidRetryAfter checks that a non-numeric
+// Retry-After value falls back to the computed exponential backoff rather
+// than failing.
+func TestBackoffDelayIgnoresInvalidRetryAfter(t *testing.T) {
+	u := NewUploader(1, 1, time.Second)
+
+	lastErr := retryableStatusError{StatusCode: 503, RetryAfter: "not-a-number"}
+	delay := u.backoffDelay(1, lastErr)
+	if delay < 0 || delay > DEFAULT_BACKOFF_BASE {
+		t.Fatalf("backoffDelay with invalid Retry-After = %v, want in [0, %v]", delay, DEFAULT_BACKOFF_BASE)
+	}
+}
+
+// TestBuildBatchPayloadWrapsRecordsAsArray checks that a batch of
+// already-marshaled JSON records is concatenated into a single JSON array
+// rather than re-encoded.
+func TestBuildBatchPayloadWrapsRecordsAsArray(t *testing.T) {
+	batch := [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}
+
+	payload, err := buildBatchPayload(batch)
+	if err != nil {
+		t.Fatalf("buildBatchPayload returned error: %v", err)
+	}
+
+	want := `[{"a":1},{"b":2}]`
+	if string(payload) != want {
+		t.Fatalf("buildBatchPayload = %s, want %s", payload, want)
+	}
+}